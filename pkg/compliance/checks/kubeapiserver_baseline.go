@@ -0,0 +1,142 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	stdjson "encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// defaultBaselineDir is where kubeApiserverCheck persists the last-observed
+// value of every PropertyKindDiff field, so that drift can be detected
+// across agent restarts without an external database.
+const defaultBaselineDir = "/opt/datadog-agent/run/compliance.d/baselines"
+
+// baselineStore persists the last-observed value of a diffed Report field.
+type baselineStore interface {
+	// GetAndSet atomically returns the previously recorded value for key (if
+	// any) and records value as the new baseline. Concurrent callers (e.g. a
+	// multi-cluster check running its clusters in parallel against one store)
+	// must never interleave their read and write of the same key, or one
+	// caller's "changed" finding can be lost or spuriously suppressed.
+	GetAndSet(key string, value string) (previous string, found bool, err error)
+}
+
+// diskBaselineStore is a baselineStore backed by one file per key under dir.
+// A single mutex serializes every read-modify-write, which is simpler than
+// per-key locking and cheap relative to the file I/O it guards.
+type diskBaselineStore struct {
+	dir string
+
+	mu sync.Mutex
+}
+
+func newDiskBaselineStore(dir string) *diskBaselineStore {
+	return &diskBaselineStore{dir: dir}
+}
+
+func (s *diskBaselineStore) GetAndSet(key string, value string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, found, err := s.get(key)
+	if err != nil {
+		return "", false, err
+	}
+	if err := s.set(key, value); err != nil {
+		return "", false, err
+	}
+	return previous, found, nil
+}
+
+func (s *diskBaselineStore) get(key string) (string, bool, error) {
+	data, err := ioutil.ReadFile(s.path(key))
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return string(data), true, nil
+}
+
+func (s *diskBaselineStore) set(key string, value string) error {
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.path(key), []byte(value), 0600)
+}
+
+func (s *diskBaselineStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".baseline")
+}
+
+// baselineKey derives the baseline store key for a single diffed field of a
+// resource: it is scoped by rule, cluster, GVK, namespace/name and the field
+// itself so that two rules (or two fields of the same rule, or the same rule
+// against two clusters) never collide. cluster is "" for single-cluster checks.
+func baselineKey(ruleID string, cluster string, p unstructured.Unstructured, property string) string {
+	gvk := p.GroupVersionKind()
+	return fmt.Sprintf("%s|%s|%s|%s|%s|%s", ruleID, cluster, gvk.String(), p.GetNamespace(), p.GetName(), property)
+}
+
+// reportDiff compares value against the last baseline recorded for key. On
+// first observation it only records the baseline. When the subtree changed
+// since the last run, it populates kv under reportName (and the
+// "_changed"/"_previous"/"_current"/"_hash" suffixed keys) and records the
+// new baseline.
+func (c *kubeApiserverCheck) reportDiff(kv compliance.KVMap, reportName string, key string, value interface{}) error {
+	currentBytes, err := stdjson.Marshal(value)
+	if err != nil {
+		return err
+	}
+	current := string(currentBytes)
+
+	previous, found, err := c.baseline.GetAndSet(key, current)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return nil
+	}
+	if previous == current {
+		return nil
+	}
+
+	sum := sha256.Sum256(currentBytes)
+
+	kv[reportName] = unifiedDiff(previous, current)
+	kv[reportName+"_changed"] = true
+	kv[reportName+"_previous"] = previous
+	kv[reportName+"_current"] = current
+	kv[reportName+"_hash"] = hex.EncodeToString(sum[:])
+
+	return nil
+}
+
+func unifiedDiff(previous, current string) string {
+	diff := difflib.UnifiedDiff{
+		A:        difflib.SplitLines(previous),
+		B:        difflib.SplitLines(current),
+		FromFile: "baseline",
+		ToFile:   "current",
+		Context:  3,
+	}
+	text, _ := difflib.GetUnifiedDiffString(diff)
+	return text
+}
@@ -0,0 +1,86 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestDiskBaselineStoreGetAndSet(t *testing.T) {
+	store := newDiskBaselineStore(t.TempDir())
+
+	previous, found, err := store.GetAndSet("key", "v1")
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.Empty(t, previous)
+
+	previous, found, err = store.GetAndSet("key", "v2")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "v1", previous)
+
+	previous, found, err = store.GetAndSet("key", "v3")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "v2", previous)
+}
+
+func TestDiskBaselineStoreGetAndSetConcurrent(t *testing.T) {
+	store := newDiskBaselineStore(t.TempDir())
+
+	const n = 50
+	var wg sync.WaitGroup
+	founds := make([]bool, n)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, found, err := store.GetAndSet("shared-key", "value")
+			require.NoError(t, err)
+			founds[i] = found
+		}(i)
+	}
+	wg.Wait()
+
+	notFoundCount := 0
+	for _, found := range founds {
+		if !found {
+			notFoundCount++
+		}
+	}
+	assert.Equal(t, 1, notFoundCount, "exactly one concurrent GetAndSet should observe no prior baseline")
+}
+
+func TestBaselineKeyScopedByCluster(t *testing.T) {
+	p := unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":      "audit-policy",
+			"namespace": "kube-system",
+		},
+	}}
+
+	a := baselineKey("rule-1", "cluster-a", p, "data")
+	b := baselineKey("rule-1", "cluster-b", p, "data")
+	unscoped := baselineKey("rule-1", "", p, "data")
+
+	assert.NotEqual(t, a, b)
+	assert.NotEqual(t, a, unscoped)
+	assert.Equal(t, a, baselineKey("rule-1", "cluster-a", p, "data"))
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	diff := unifiedDiff("a\nb\nc\n", "a\nx\nc\n")
+	assert.Contains(t, diff, "-b")
+	assert.Contains(t, diff, "+x")
+}
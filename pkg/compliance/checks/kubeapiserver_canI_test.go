@@ -0,0 +1,43 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchesResourceRule(t *testing.T) {
+	rule := map[string]interface{}{
+		"apiGroups": []interface{}{""},
+		"resources": []interface{}{"pods", "secrets"},
+		"verbs":     []interface{}{"get", "list"},
+	}
+
+	assert.True(t, matchesResourceRule(rule, "", "secrets", "list"))
+	assert.False(t, matchesResourceRule(rule, "", "secrets", "delete"))
+	assert.False(t, matchesResourceRule(rule, "", "configmaps", "get"))
+	assert.False(t, matchesResourceRule(rule, "apps", "secrets", "get"))
+}
+
+func TestMatchesResourceRuleWildcards(t *testing.T) {
+	rule := map[string]interface{}{
+		"apiGroups": []interface{}{"*"},
+		"resources": []interface{}{"*"},
+		"verbs":     []interface{}{"*"},
+	}
+
+	assert.True(t, matchesResourceRule(rule, "apps", "deployments", "delete"))
+}
+
+func TestRuleAllows(t *testing.T) {
+	rule := map[string]interface{}{"verbs": []interface{}{"get", "watch"}}
+
+	assert.True(t, ruleAllows(rule, "verbs", "get"))
+	assert.False(t, ruleAllows(rule, "verbs", "list"))
+	assert.False(t, ruleAllows(rule, "missingKey", "get"))
+}
@@ -20,8 +20,9 @@ import (
 
 type kubeApiserverCheck struct {
 	baseCheck
-	kubeClient   kubeDynamic.Interface
+	kubeClient   kubeClientResolver
 	kubeResource compliance.KubernetesResource
+	baseline     baselineStore
 }
 
 const (
@@ -30,13 +31,24 @@ const (
 	kubeResourceVersionKey   string = "kube_resource_version"
 	kubeResourceNamespaceKey string = "kube_resource_namespace"
 	kubeResourceKindKey      string = "kube_resource_kind"
+
+	kubeCanIAllowedKey         string = "kube_can_i_allowed"
+	kubeCanIDeniedKey          string = "kube_can_i_denied"
+	kubeCanIReasonKey          string = "kube_can_i_reason"
+	kubeCanIEvaluationErrorKey string = "kube_can_i_evaluation_error"
+)
+
+var (
+	selfSubjectAccessReviewSchema = schema.GroupVersionResource{Group: "authorization.k8s.io", Version: "v1", Resource: "selfsubjectaccessreviews"}
+	selfSubjectRulesReviewSchema  = schema.GroupVersionResource{Group: "authorization.k8s.io", Version: "v1", Resource: "selfsubjectrulesreviews"}
 )
 
-func newKubeapiserverCheck(baseCheck baseCheck, kubeResource *compliance.KubernetesResource, kubeClient kubeDynamic.Interface) (*kubeApiserverCheck, error) {
+func newKubeapiserverCheck(baseCheck baseCheck, kubeResource *compliance.KubernetesResource, kubeClient kubeClientResolver) (*kubeApiserverCheck, error) {
 	check := &kubeApiserverCheck{
 		baseCheck:    baseCheck,
 		kubeClient:   kubeClient,
 		kubeResource: *kubeResource,
+		baseline:     newDiskBaselineStore(defaultBaselineDir),
 	}
 
 	if len(check.kubeResource.Kind) == 0 {
@@ -54,21 +66,91 @@ func newKubeapiserverCheck(baseCheck baseCheck, kubeResource *compliance.Kuberne
 	return check, nil
 }
 
+var namespaceResourceSchema = schema.GroupVersionResource{Group: "", Version: "v1", Resource: "namespaces"}
+
 func (c *kubeApiserverCheck) Run() error {
 	log.Debugf("%s: kubeapiserver check: %v", c.ruleID, c.kubeResource)
 
+	clusters, err := c.resolveClusters()
+	if err != nil {
+		return err
+	}
+
+	if len(clusters) == 0 {
+		kubeClient, err := c.kubeClient.Get("")
+		if err != nil {
+			return fmt.Errorf("%s: unable to get kube client, err: %v", c.ruleID, err)
+		}
+		return c.runCluster(kubeClient, "")
+	}
+
+	return c.runClusters(clusters)
+}
+
+// runCluster runs the configured APIRequest against a single cluster's
+// dynamic client. cluster is "" for single-cluster checks and is otherwise
+// the cluster name to tag every reported finding with.
+func (c *kubeApiserverCheck) runCluster(kubeClient kubeDynamic.Interface, cluster string) error {
+	if c.kubeResource.APIRequest.Verb == "canI" {
+		return c.runCanI(kubeClient, cluster)
+	}
+
 	resourceSchema := schema.GroupVersionResource{
 		Group:    c.kubeResource.Group,
 		Resource: c.kubeResource.Kind,
 		Version:  c.kubeResource.Version,
 	}
-	resourceDef := c.kubeClient.Resource(resourceSchema)
+	resourceDef := kubeClient.Resource(resourceSchema)
 
-	var resourceAPI kubeDynamic.ResourceInterface
-	if len(c.kubeResource.Namespace) > 0 {
-		resourceAPI = resourceDef.Namespace(c.kubeResource.Namespace)
-	} else {
-		resourceAPI = resourceDef
+	namespaces, err := c.resolveNamespaces(kubeClient)
+	if err != nil {
+		return err
+	}
+
+	if len(namespaces) == 0 {
+		return c.runOne(resourceDef, resourceSchema, "", kubeClient, cluster)
+	}
+
+	for _, namespace := range namespaces {
+		if err := c.runOne(resourceDef.Namespace(namespace), resourceSchema, namespace, kubeClient, cluster); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resolveNamespaces expands c.kubeResource.Namespace(s) into the concrete list
+// of namespaces to iterate the resource across. It returns an empty slice,
+// meaning an unscoped request, when neither Namespace nor Namespaces is set;
+// the resource's own scope is not inspected.
+func (c *kubeApiserverCheck) resolveNamespaces(kubeClient kubeDynamic.Interface) ([]string, error) {
+	if len(c.kubeResource.Namespaces) == 0 {
+		if len(c.kubeResource.Namespace) > 0 {
+			return []string{c.kubeResource.Namespace}, nil
+		}
+		return nil, nil
+	}
+
+	if len(c.kubeResource.Namespaces) == 1 && c.kubeResource.Namespaces[0] == "*" {
+		list, err := kubeClient.Resource(namespaceResourceSchema).List(metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("%s: unable to discover namespaces, err: %v", c.ruleID, err)
+		}
+		namespaces := make([]string, 0, len(list.Items))
+		for _, ns := range list.Items {
+			namespaces = append(namespaces, ns.GetName())
+		}
+		return namespaces, nil
+	}
+
+	return c.kubeResource.Namespaces, nil
+}
+
+func (c *kubeApiserverCheck) runOne(resourceAPI kubeDynamic.ResourceInterface, resourceSchema schema.GroupVersionResource, namespace string, kubeClient kubeDynamic.Interface, cluster string) error {
+	listOptions := metav1.ListOptions{
+		LabelSelector: c.kubeResource.APIRequest.LabelSelector,
+		FieldSelector: c.kubeResource.APIRequest.FieldSelector,
 	}
 
 	var resources []unstructured.Unstructured
@@ -79,20 +161,20 @@ func (c *kubeApiserverCheck) Run() error {
 		}
 		resource, err := resourceAPI.Get(c.kubeResource.APIRequest.ResourceName, metav1.GetOptions{})
 		if err != nil {
-			return fmt.Errorf("Unable to get Kube resource:'%v', ns:'%s' name:'%s', err: %v", resourceSchema, c.kubeResource.Namespace, c.kubeResource.APIRequest.ResourceName, err)
+			return fmt.Errorf("Unable to get Kube resource:'%v', ns:'%s' name:'%s', err: %v", resourceSchema, namespace, c.kubeResource.APIRequest.ResourceName, err)
 		}
 		resources = []unstructured.Unstructured{*resource}
 	case "list":
-		list, err := resourceAPI.List(metav1.ListOptions{})
+		list, err := resourceAPI.List(listOptions)
 		if err != nil {
-			return fmt.Errorf("Unable to list Kube resources:'%v', ns:'%s' name:'%s', err: %v", resourceSchema, c.kubeResource.Namespace, c.kubeResource.APIRequest.ResourceName, err)
+			return fmt.Errorf("Unable to list Kube resources:'%v', ns:'%s' name:'%s', err: %v", resourceSchema, namespace, c.kubeResource.APIRequest.ResourceName, err)
 		}
 		resources = list.Items
 	}
 
 	log.Debugf("%s: Got %d resources", c.ruleID, len(resources))
 	for _, resource := range resources {
-		if err := c.reportResource(resource); err != nil {
+		if err := c.reportResource(resource, kubeClient, cluster); err != nil {
 			return err
 		}
 	}
@@ -100,15 +182,54 @@ func (c *kubeApiserverCheck) Run() error {
 	return nil
 }
 
-func (c *kubeApiserverCheck) reportResource(p unstructured.Unstructured) error {
+func (c *kubeApiserverCheck) reportResource(p unstructured.Unstructured, kubeClient kubeDynamic.Interface, cluster string) error {
+	kv, err := c.buildReportedFields(p, kubeClient, cluster)
+	if err != nil {
+		return err
+	}
+
+	if len(kv) > 0 {
+		kv[kubeResourceKindKey] = p.GetObjectKind().GroupVersionKind().Kind
+		kv[kubeResourceGroupKey] = p.GetObjectKind().GroupVersionKind().Group
+		kv[kubeResourceVersionKey] = p.GetObjectKind().GroupVersionKind().Version
+		kv[kubeResourceNamespaceKey] = p.GetNamespace()
+		kv[kubeResourceNameKey] = p.GetName()
+		if len(cluster) > 0 {
+			kv[kubeClusterKey] = cluster
+		}
+	}
+
+	c.report(nil, kv)
+	return nil
+}
+
+// buildReportedFields runs the configured Report projections against p and
+// returns the resulting KVMap. It is shared by reportResource and the "canI"
+// verb, which reports the outcome of an access review rather than a fetched
+// resource.
+func (c *kubeApiserverCheck) buildReportedFields(p unstructured.Unstructured, kubeClient kubeDynamic.Interface, cluster string) (compliance.KVMap, error) {
 	kv := compliance.KVMap{}
 
 	for _, field := range c.kubeResource.Report {
 		switch field.Kind {
-		case compliance.PropertyKindJSONQuery:
-			reportValue, valueFound, err := json.RunSingleOutput(field.Property, p.Object)
+		case compliance.PropertyKindJSONQuery, compliance.PropertyKindJSONPath, compliance.PropertyKindGoTemplate, compliance.PropertyKindLookup:
+			var (
+				reportValue interface{}
+				valueFound  bool
+				err         error
+			)
+			switch field.Kind {
+			case compliance.PropertyKindJSONQuery:
+				reportValue, valueFound, err = json.RunSingleOutput(field.Property, p.Object)
+			case compliance.PropertyKindJSONPath:
+				reportValue, valueFound, err = reportJSONPath(field.Property, p)
+			case compliance.PropertyKindGoTemplate:
+				reportValue, valueFound, err = reportGoTemplate(field.Property, p)
+			case compliance.PropertyKindLookup:
+				reportValue, valueFound, err = c.reportLookup(field, p, kubeClient)
+			}
 			if err != nil {
-				return fmt.Errorf("Unable to report field: '%s' for kubernetes object '%s / %s / %s' - json query error: %v", field.Property, p.GroupVersionKind().String(), p.GetNamespace(), p.GetName(), err)
+				return nil, fmt.Errorf("Unable to report field: '%s' (kind '%s') for kubernetes object '%s / %s / %s' - err: %v", field.Property, field.Kind, p.GroupVersionKind().String(), p.GetNamespace(), p.GetName(), err)
 			}
 
 			if !valueFound {
@@ -124,19 +245,148 @@ func (c *kubeApiserverCheck) reportResource(p unstructured.Unstructured) error {
 			}
 
 			kv[reportName] = reportValue
+		case compliance.PropertyKindDiff:
+			reportValue, valueFound, err := json.RunSingleOutput(field.Property, p.Object)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to report field: '%s' for kubernetes object '%s / %s / %s' - json query error: %v", field.Property, p.GroupVersionKind().String(), p.GetNamespace(), p.GetName(), err)
+			}
+
+			if !valueFound {
+				continue
+			}
+
+			reportName := field.Property
+			if len(field.As) > 0 {
+				reportName = field.As
+			}
+
+			if err := c.reportDiff(kv, reportName, baselineKey(c.ruleID, cluster, p, field.Property), reportValue); err != nil {
+				return nil, fmt.Errorf("Unable to diff field: '%s' for kubernetes object '%s / %s / %s' against baseline - err: %v", field.Property, p.GroupVersionKind().String(), p.GetNamespace(), p.GetName(), err)
+			}
 		default:
-			return fmt.Errorf("Unsupported kind value: '%s' for KubeResource", field.Kind)
+			return nil, fmt.Errorf("Unsupported kind value: '%s' for KubeResource", field.Kind)
 		}
 	}
 
-	if len(kv) > 0 {
-		kv[kubeResourceKindKey] = p.GetObjectKind().GroupVersionKind().Kind
-		kv[kubeResourceGroupKey] = p.GetObjectKind().GroupVersionKind().Group
-		kv[kubeResourceVersionKey] = p.GetObjectKind().GroupVersionKind().Version
-		kv[kubeResourceNamespaceKey] = p.GetNamespace()
-		kv[kubeResourceNameKey] = p.GetName()
+	return kv, nil
+}
+
+// runCanI issues an authorization.k8s.io SelfSubjectAccessReview (when a
+// resource name is given) or a SelfSubjectRulesReview (when it is not) and
+// reports the outcome as a compliance finding, mirroring how `kubectl auth
+// can-i` answers the same question.
+func (c *kubeApiserverCheck) runCanI(kubeClient kubeDynamic.Interface, cluster string) error {
+	if len(c.kubeResource.APIRequest.Action) == 0 {
+		return fmt.Errorf("%s: unable to use 'canI' apirequest without an action", c.ruleID)
+	}
+
+	if len(c.kubeResource.APIRequest.ResourceName) == 0 {
+		return c.runSelfSubjectRulesReview(kubeClient, cluster)
+	}
+	return c.runSelfSubjectAccessReview(kubeClient, cluster)
+}
+
+func (c *kubeApiserverCheck) runSelfSubjectAccessReview(kubeClient kubeDynamic.Interface, cluster string) error {
+	review := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "authorization.k8s.io/v1",
+			"kind":       "SelfSubjectAccessReview",
+			"spec": map[string]interface{}{
+				"resourceAttributes": map[string]interface{}{
+					"namespace": c.kubeResource.Namespace,
+					"verb":      c.kubeResource.APIRequest.Action,
+					"group":     c.kubeResource.Group,
+					"resource":  c.kubeResource.Kind,
+					"name":      c.kubeResource.APIRequest.ResourceName,
+				},
+			},
+		},
+	}
+
+	result, err := kubeClient.Resource(selfSubjectAccessReviewSchema).Create(review, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("%s: unable to create SelfSubjectAccessReview, err: %v", c.ruleID, err)
+	}
+
+	kv, err := c.buildReportedFields(*result, kubeClient, cluster)
+	if err != nil {
+		return err
+	}
+
+	allowed, _, _ := unstructured.NestedBool(result.Object, "status", "allowed")
+	denied, _, _ := unstructured.NestedBool(result.Object, "status", "denied")
+	reason, _, _ := unstructured.NestedString(result.Object, "status", "reason")
+	evaluationError, _, _ := unstructured.NestedString(result.Object, "status", "evaluationError")
+
+	kv[kubeCanIAllowedKey] = allowed
+	kv[kubeCanIDeniedKey] = denied || !allowed
+	if len(reason) > 0 {
+		kv[kubeCanIReasonKey] = reason
+	}
+	if len(evaluationError) > 0 {
+		kv[kubeCanIEvaluationErrorKey] = evaluationError
+	}
+	if len(cluster) > 0 {
+		kv[kubeClusterKey] = cluster
 	}
 
 	c.report(nil, kv)
 	return nil
 }
+
+func (c *kubeApiserverCheck) runSelfSubjectRulesReview(kubeClient kubeDynamic.Interface, cluster string) error {
+	review := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "authorization.k8s.io/v1",
+			"kind":       "SelfSubjectRulesReview",
+			"spec": map[string]interface{}{
+				"namespace": c.kubeResource.Namespace,
+			},
+		},
+	}
+
+	result, err := kubeClient.Resource(selfSubjectRulesReviewSchema).Create(review, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("%s: unable to create SelfSubjectRulesReview, err: %v", c.ruleID, err)
+	}
+
+	kv, err := c.buildReportedFields(*result, kubeClient, cluster)
+	if err != nil {
+		return err
+	}
+
+	rules, _, _ := unstructured.NestedSlice(result.Object, "status", "resourceRules")
+	allowed := false
+	for _, r := range rules {
+		if rule, ok := r.(map[string]interface{}); ok && matchesResourceRule(rule, c.kubeResource.Group, c.kubeResource.Kind, c.kubeResource.APIRequest.Action) {
+			allowed = true
+			break
+		}
+	}
+
+	kv[kubeCanIAllowedKey] = allowed
+	kv[kubeCanIDeniedKey] = !allowed
+	if len(cluster) > 0 {
+		kv[kubeClusterKey] = cluster
+	}
+
+	c.report(nil, kv)
+	return nil
+}
+
+// matchesResourceRule reports whether a PolicyRule-shaped map (as found in a
+// SelfSubjectRulesReview's status.resourceRules) grants verb on resource in
+// group, treating "*" entries as wildcards.
+func matchesResourceRule(rule map[string]interface{}, group, resource, verb string) bool {
+	return ruleAllows(rule, "apiGroups", group) && ruleAllows(rule, "resources", resource) && ruleAllows(rule, "verbs", verb)
+}
+
+func ruleAllows(rule map[string]interface{}, key, want string) bool {
+	values, _, _ := unstructured.NestedStringSlice(rule, key)
+	for _, value := range values {
+		if value == "*" || value == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,175 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	kubeDynamic "k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const kubeClusterKey string = "kube_cluster"
+
+// maxConcurrentClusterChecks bounds how many clusters a multi-cluster
+// kubeApiserverCheck talks to at once, so a rule with Clusters: ["*"] against
+// a large fleet doesn't open every apiserver connection simultaneously.
+const maxConcurrentClusterChecks = 5
+
+// kubeClientResolver resolves the dynamic client to use for a given
+// kubeconfig context/cluster name, and enumerates every cluster known to the
+// agent's kubeconfig when a check requests Clusters: ["*"].
+type kubeClientResolver interface {
+	// Get returns the dynamic client for cluster. An empty cluster name
+	// resolves to the kubeconfig's current context.
+	Get(cluster string) (kubeDynamic.Interface, error)
+	// Clusters lists every cluster/context the resolver can Get.
+	Clusters() ([]string, error)
+}
+
+// resolveClusters expands c.kubeResource.Clusters into the concrete list of
+// clusters to run the check against. An empty slice means single-cluster
+// behavior: use the resolver's current/default context.
+func (c *kubeApiserverCheck) resolveClusters() ([]string, error) {
+	if len(c.kubeResource.Clusters) == 0 {
+		return nil, nil
+	}
+
+	if len(c.kubeResource.Clusters) == 1 && c.kubeResource.Clusters[0] == "*" {
+		clusters, err := c.kubeClient.Clusters()
+		if err != nil {
+			return nil, fmt.Errorf("%s: unable to discover clusters, err: %v", c.ruleID, err)
+		}
+		return clusters, nil
+	}
+
+	return c.kubeResource.Clusters, nil
+}
+
+// runClusters fans runCluster out across clusters in parallel, bounded by
+// maxConcurrentClusterChecks. Every cluster is run even if another fails; the
+// error returned is that of the first failing cluster in clusters order.
+func (c *kubeApiserverCheck) runClusters(clusters []string) error {
+	sem := make(chan struct{}, maxConcurrentClusterChecks)
+	errs := make([]error, len(clusters))
+
+	var wg sync.WaitGroup
+	for i, cluster := range clusters {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, cluster string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			kubeClient, err := c.kubeClient.Get(cluster)
+			if err != nil {
+				errs[i] = fmt.Errorf("%s: unable to get client for cluster '%s', err: %v", c.ruleID, cluster, err)
+				return
+			}
+			errs[i] = c.runCluster(kubeClient, cluster)
+		}(i, cluster)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// singleClusterResolver adapts a single, already-constructed dynamic client
+// into a kubeClientResolver. It is the migration path for the pre-multi-cluster
+// construction site: any cluster name (including "") resolves to the same
+// client, and Clusters() reports that one context name.
+type singleClusterResolver struct {
+	cluster string
+	client  kubeDynamic.Interface
+}
+
+// newSingleClusterResolver wraps client so it can be passed to
+// newKubeapiserverCheck unchanged from call sites that only ever dealt with
+// one cluster. cluster is the name reported by Clusters() and used to tag
+// findings when the rule nonetheless sets Clusters: ["*"] or names it explicitly.
+func newSingleClusterResolver(cluster string, client kubeDynamic.Interface) kubeClientResolver {
+	return &singleClusterResolver{cluster: cluster, client: client}
+}
+
+func (r *singleClusterResolver) Get(cluster string) (kubeDynamic.Interface, error) {
+	return r.client, nil
+}
+
+func (r *singleClusterResolver) Clusters() ([]string, error) {
+	return []string{r.cluster}, nil
+}
+
+// kubeconfigClientResolver resolves dynamic clients from the contexts defined
+// in a kubeconfig, caching one client per context name so that Clusters: ["*"]
+// against a large fleet doesn't rebuild a client (and its transport) on every run.
+type kubeconfigClientResolver struct {
+	loadingRules *clientcmd.ClientConfigLoadingRules
+
+	mu      sync.Mutex
+	clients map[string]kubeDynamic.Interface
+}
+
+// newKubeconfigClientResolver builds a resolver over the kubeconfig at path.
+// An empty path falls back to the default kubeconfig loading rules (KUBECONFIG
+// env var, then ~/.kube/config), matching kubectl's own resolution order.
+func newKubeconfigClientResolver(path string) *kubeconfigClientResolver {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if len(path) > 0 {
+		rules.ExplicitPath = path
+	}
+	return &kubeconfigClientResolver{
+		loadingRules: rules,
+		clients:      make(map[string]kubeDynamic.Interface),
+	}
+}
+
+func (r *kubeconfigClientResolver) Get(cluster string) (kubeDynamic.Interface, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if client, ok := r.clients[cluster]; ok {
+		return client, nil
+	}
+
+	overrides := &clientcmd.ConfigOverrides{}
+	if len(cluster) > 0 {
+		overrides.CurrentContext = cluster
+	}
+
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(r.loadingRules, overrides).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("unable to build rest config for cluster '%s': %v", cluster, err)
+	}
+
+	client, err := kubeDynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build dynamic client for cluster '%s': %v", cluster, err)
+	}
+
+	r.clients[cluster] = client
+	return client, nil
+}
+
+func (r *kubeconfigClientResolver) Clusters() ([]string, error) {
+	config, err := r.loadingRules.Load()
+	if err != nil {
+		return nil, fmt.Errorf("unable to load kubeconfig: %v", err)
+	}
+
+	clusters := make([]string, 0, len(config.Contexts))
+	for name := range config.Contexts {
+		clusters = append(clusters, name)
+	}
+	sort.Strings(clusters)
+	return clusters, nil
+}
@@ -0,0 +1,110 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
+	kubeDynamic "k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+type stubClientResolver struct {
+	clusters []string
+}
+
+func (r *stubClientResolver) Get(cluster string) (kubeDynamic.Interface, error) {
+	return fake.NewSimpleDynamicClient(apiruntime.NewScheme()), nil
+}
+
+func (r *stubClientResolver) Clusters() ([]string, error) {
+	return r.clusters, nil
+}
+
+func TestResolveClusters(t *testing.T) {
+	resolver := &stubClientResolver{clusters: []string{"prod-us", "prod-eu"}}
+
+	t.Run("unscoped", func(t *testing.T) {
+		check := &kubeApiserverCheck{kubeClient: resolver}
+		clusters, err := check.resolveClusters()
+		require.NoError(t, err)
+		assert.Empty(t, clusters)
+	})
+
+	t.Run("curated list", func(t *testing.T) {
+		check := &kubeApiserverCheck{kubeClient: resolver, kubeResource: compliance.KubernetesResource{Clusters: []string{"a", "b"}}}
+		clusters, err := check.resolveClusters()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b"}, clusters)
+	})
+
+	t.Run("wildcard discovers every cluster", func(t *testing.T) {
+		check := &kubeApiserverCheck{kubeClient: resolver, kubeResource: compliance.KubernetesResource{Clusters: []string{"*"}}}
+		clusters, err := check.resolveClusters()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"prod-us", "prod-eu"}, clusters)
+	})
+}
+
+func TestSingleClusterResolver(t *testing.T) {
+	client := fake.NewSimpleDynamicClient(apiruntime.NewScheme())
+	resolver := newSingleClusterResolver("current", client)
+
+	got, err := resolver.Get("anything")
+	require.NoError(t, err)
+	assert.Same(t, client, got)
+
+	clusters, err := resolver.Clusters()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"current"}, clusters)
+}
+
+const testKubeconfig = `
+apiVersion: v1
+kind: Config
+clusters:
+- name: cluster-a
+  cluster:
+    server: https://cluster-a.example.com
+- name: cluster-b
+  cluster:
+    server: https://cluster-b.example.com
+contexts:
+- name: cluster-a
+  context:
+    cluster: cluster-a
+- name: cluster-b
+  context:
+    cluster: cluster-b
+current-context: cluster-a
+users: []
+`
+
+func TestKubeconfigClientResolverClusters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	require.NoError(t, ioutil.WriteFile(path, []byte(testKubeconfig), 0600))
+
+	resolver := newKubeconfigClientResolver(path)
+
+	clusters, err := resolver.Clusters()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cluster-a", "cluster-b"}, clusters)
+
+	client, err := resolver.Get("cluster-a")
+	require.NoError(t, err)
+	assert.NotNil(t, client)
+
+	cached, err := resolver.Get("cluster-a")
+	require.NoError(t, err)
+	assert.Same(t, client, cached)
+}
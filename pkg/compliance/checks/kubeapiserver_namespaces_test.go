@@ -0,0 +1,65 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+func newNamespace(name string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Namespace",
+		"metadata": map[string]interface{}{
+			"name": name,
+		},
+	}}
+}
+
+func TestResolveNamespaces(t *testing.T) {
+	gvrToListKind := map[schema.GroupVersionResource]string{
+		namespaceResourceSchema: "NamespaceList",
+	}
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), gvrToListKind,
+		newNamespace("kube-system"), newNamespace("default"))
+
+	t.Run("unscoped", func(t *testing.T) {
+		check := &kubeApiserverCheck{kubeResource: compliance.KubernetesResource{}}
+		namespaces, err := check.resolveNamespaces(client)
+		require.NoError(t, err)
+		assert.Empty(t, namespaces)
+	})
+
+	t.Run("single namespace", func(t *testing.T) {
+		check := &kubeApiserverCheck{kubeResource: compliance.KubernetesResource{Namespace: "kube-system"}}
+		namespaces, err := check.resolveNamespaces(client)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"kube-system"}, namespaces)
+	})
+
+	t.Run("curated list", func(t *testing.T) {
+		check := &kubeApiserverCheck{kubeResource: compliance.KubernetesResource{Namespaces: []string{"a", "b"}}}
+		namespaces, err := check.resolveNamespaces(client)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b"}, namespaces)
+	})
+
+	t.Run("wildcard discovers every namespace", func(t *testing.T) {
+		check := &kubeApiserverCheck{kubeResource: compliance.KubernetesResource{Namespaces: []string{"*"}}}
+		namespaces, err := check.resolveNamespaces(client)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"kube-system", "default"}, namespaces)
+	})
+}
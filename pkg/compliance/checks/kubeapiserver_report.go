@@ -0,0 +1,119 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance"
+	"github.com/DataDog/datadog-agent/pkg/util/json"
+
+	"github.com/Masterminds/sprig/v3"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kubeDynamic "k8s.io/client-go/dynamic"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// reportJSONPath evaluates a kubectl-style JSONPath expression against p. It
+// mirrors json.RunSingleOutput's (value, found, error) contract: a path that
+// doesn't match the resource reports "not found" rather than an error.
+func reportJSONPath(expression string, p unstructured.Unstructured) (interface{}, bool, error) {
+	jp := jsonpath.New(expression)
+	jp.AllowMissingKeys(true)
+	if err := jp.Parse(expression); err != nil {
+		return nil, false, fmt.Errorf("invalid jsonpath expression '%s': %v", expression, err)
+	}
+
+	var buf bytes.Buffer
+	if err := jp.Execute(&buf, p.Object); err != nil {
+		return nil, false, fmt.Errorf("unable to execute jsonpath expression '%s': %v", expression, err)
+	}
+
+	if buf.Len() == 0 {
+		return nil, false, nil
+	}
+
+	return buf.String(), true, nil
+}
+
+// reportGoTemplate executes a text/template (with sprig functions) against p,
+// with the resource's object graph available as the template's ".".
+func reportGoTemplate(text string, p unstructured.Unstructured) (interface{}, bool, error) {
+	tmpl, err := template.New("report").Funcs(sprig.TxtFuncMap()).Parse(text)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid template '%s': %v", text, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, p.Object); err != nil {
+		return nil, false, fmt.Errorf("unable to execute template '%s': %v", text, err)
+	}
+
+	return buf.String(), true, nil
+}
+
+// reportLookup fetches the resource identified by field.Lookup (each of its
+// fields may itself be a template evaluated against p) and then runs
+// field.Property as a JSON query against it, Helm `lookup`-function style:
+// Lookup.Kind is the resource Kind (e.g. "ServiceAccount"), not the dynamic
+// client's plural resource name, and is converted via
+// meta.UnsafeGuessKindToResource the same way client-go tooling falls back to
+// a naive pluralization when no RESTMapper/discovery client is available.
+func (c *kubeApiserverCheck) reportLookup(field compliance.ReportedField, p unstructured.Unstructured, kubeClient kubeDynamic.Interface) (interface{}, bool, error) {
+	apiVersion, err := renderLookupTemplate(field.Lookup.APIVersion, p)
+	if err != nil {
+		return nil, false, err
+	}
+	kind, err := renderLookupTemplate(field.Lookup.Kind, p)
+	if err != nil {
+		return nil, false, err
+	}
+	namespace, err := renderLookupTemplate(field.Lookup.Namespace, p)
+	if err != nil {
+		return nil, false, err
+	}
+	name, err := renderLookupTemplate(field.Lookup.Name, p)
+	if err != nil {
+		return nil, false, err
+	}
+
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid lookup apiVersion '%s': %v", apiVersion, err)
+	}
+	resourceSchema, _ := meta.UnsafeGuessKindToResource(gv.WithKind(kind))
+
+	resourceDef := kubeClient.Resource(resourceSchema)
+	var resourceAPI kubeDynamic.ResourceInterface
+	if len(namespace) > 0 {
+		resourceAPI = resourceDef.Namespace(namespace)
+	} else {
+		resourceAPI = resourceDef
+	}
+
+	looked, err := resourceAPI.Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to lookup '%v' ns:'%s' name:'%s', err: %v", resourceSchema, namespace, name, err)
+	}
+
+	return json.RunSingleOutput(field.Property, looked.Object)
+}
+
+func renderLookupTemplate(text string, p unstructured.Unstructured) (string, error) {
+	if len(text) == 0 {
+		return "", nil
+	}
+	value, _, err := reportGoTemplate(text, p)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%v", value), nil
+}
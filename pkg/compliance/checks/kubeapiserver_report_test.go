@@ -0,0 +1,133 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package checks
+
+import (
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/compliance"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+func newDeployment() unstructured.Unstructured {
+	return unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]interface{}{
+			"name":      "app",
+			"namespace": "ns1",
+		},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"serviceAccountName": "default",
+				},
+			},
+		},
+	}}
+}
+
+func TestReportJSONPath(t *testing.T) {
+	p := newDeployment()
+
+	value, found, err := reportJSONPath("{.spec.template.spec.serviceAccountName}", p)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "default", value)
+
+	_, found, err = reportJSONPath("{.spec.template.spec.doesNotExist}", p)
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	_, _, err = reportJSONPath("{.spec.template.spec.serviceAccountName", p)
+	assert.Error(t, err)
+
+	_, _, err = reportJSONPath("{.spec.template.spec.serviceAccountName[0]}", p)
+	assert.Error(t, err)
+}
+
+func TestReportGoTemplate(t *testing.T) {
+	p := newDeployment()
+
+	value, found, err := reportGoTemplate(`{{ .spec.template.spec.serviceAccountName }}`, p)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "default", value)
+
+	_, _, err = reportGoTemplate(`{{ .spec.template.spec.serviceAccountName`, p)
+	assert.Error(t, err)
+}
+
+func TestReportLookup(t *testing.T) {
+	serviceAccount := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion":                   "v1",
+		"kind":                         "ServiceAccount",
+		"automountServiceAccountToken": false,
+		"metadata": map[string]interface{}{
+			"name":      "default",
+			"namespace": "ns1",
+		},
+	}}
+
+	gvrToListKind := map[schema.GroupVersionResource]string{
+		{Version: "v1", Resource: "serviceaccounts"}: "ServiceAccountList",
+	}
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), gvrToListKind, serviceAccount)
+
+	check := &kubeApiserverCheck{}
+	field := compliance.ReportedField{
+		Property: ".automountServiceAccountToken",
+		Lookup: compliance.ReportedFieldLookup{
+			APIVersion: "v1",
+			Kind:       "ServiceAccount",
+			Namespace:  "ns1",
+			Name:       "default",
+		},
+	}
+
+	value, found, err := check.reportLookup(field, newDeployment(), client)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, false, value)
+}
+
+func TestReportLookupTemplatedFields(t *testing.T) {
+	serviceAccount := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ServiceAccount",
+		"metadata": map[string]interface{}{
+			"name":      "default",
+			"namespace": "ns1",
+		},
+	}}
+
+	gvrToListKind := map[schema.GroupVersionResource]string{
+		{Version: "v1", Resource: "serviceaccounts"}: "ServiceAccountList",
+	}
+	client := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), gvrToListKind, serviceAccount)
+
+	check := &kubeApiserverCheck{}
+	field := compliance.ReportedField{
+		Property: ".metadata.name",
+		Lookup: compliance.ReportedFieldLookup{
+			APIVersion: "v1",
+			Kind:       "ServiceAccount",
+			Namespace:  `{{ .metadata.namespace }}`,
+			Name:       `{{ .spec.template.spec.serviceAccountName }}`,
+		},
+	}
+
+	value, found, err := check.reportLookup(field, newDeployment(), client)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "default", value)
+}
@@ -0,0 +1,95 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-2020 Datadog, Inc.
+
+package compliance
+
+// KVMap is a collection of key/value pairs reported by a compliance check.
+type KVMap map[string]interface{}
+
+const (
+	// PropertyKindJSONQuery reports a field by running a JSON query against the resource.
+	PropertyKindJSONQuery = "jsonquery"
+
+	// PropertyKindDiff reports a field as a unified diff against the value
+	// last observed for the same rule/resource/property, along with a
+	// "<name>_changed" boolean that is always true. Nothing is reported the
+	// first time a field is seen, nor while its value is unchanged.
+	PropertyKindDiff = "diff"
+
+	// PropertyKindJSONPath reports a field using a kubectl-style JSONPath
+	// expression (e.g. "{.spec.template.spec.serviceAccountName}").
+	PropertyKindJSONPath = "jsonpath"
+
+	// PropertyKindGoTemplate reports a field by executing a text/template
+	// (with sprig functions) against the resource.
+	PropertyKindGoTemplate = "template"
+
+	// PropertyKindLookup reports a field fetched from a related resource,
+	// Helm-style: Lookup identifies the related resource (each of its fields
+	// may be templated against the current resource), and Property is then
+	// evaluated as a JSON query against that resource.
+	PropertyKindLookup = "lookup"
+)
+
+// ReportedField describes how to extract and label a single property of a
+// Kubernetes resource when it is reported as a compliance finding.
+type ReportedField struct {
+	Kind     string              `yaml:"kind"`
+	Property string              `yaml:"property"`
+	As       string              `yaml:"as"`
+	Value    string              `yaml:"value"`
+	Lookup   ReportedFieldLookup `yaml:"lookup"`
+}
+
+// ReportedFieldLookup identifies a resource related to the one a
+// PropertyKindLookup field is evaluated against. Every field may be
+// templated (text/template, with the current resource as "."). Kind is the
+// resource Kind (e.g. "ServiceAccount"), not the dynamic client's plural
+// resource name; it is converted with meta.UnsafeGuessKindToResource.
+type ReportedFieldLookup struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Namespace  string `yaml:"namespace"`
+	Name       string `yaml:"name"`
+}
+
+// KubernetesAPIRequest describes the Kubernetes API call a kubeApiserverCheck performs.
+//
+// Action is only used by the "canI" verb: it is the verb (get/list/watch/
+// create/update/patch/delete/deletecollection) being tested against the
+// resource described by the surrounding KubernetesResource.
+type KubernetesAPIRequest struct {
+	Verb          string `yaml:"verb"`
+	ResourceName  string `yaml:"resourceName"`
+	LabelSelector string `yaml:"labelSelector"`
+	FieldSelector string `yaml:"fieldSelector"`
+	Action        string `yaml:"action"`
+}
+
+// KubernetesResource describes a Kubernetes resource that a kubeApiserverCheck
+// targets, the API request used to fetch it and the fields to report once fetched.
+//
+// Namespace restricts the request to a single namespace. Namespaces is an
+// alternative to Namespace that accepts a curated list of namespaces to
+// iterate the resource across, or the single entry "*" to discover and
+// iterate every namespace in the cluster. Namespace and Namespaces are
+// mutually exclusive; Namespaces takes precedence when both are set.
+//
+// Clusters turns the check into a fleet-wide assertion: a curated list of
+// kubeconfig context names to run the same request against, or the single
+// entry "*" for every context the resolver knows about. Every finding
+// reported while Clusters is set is tagged with a kube_cluster key. An empty
+// Clusters means single-cluster behavior against the resolver's current
+// context, as before.
+type KubernetesResource struct {
+	Kind       string               `yaml:"kind"`
+	Group      string               `yaml:"group"`
+	Version    string               `yaml:"version"`
+	Namespace  string               `yaml:"namespace"`
+	Namespaces []string             `yaml:"namespaces"`
+	Clusters   []string             `yaml:"clusters"`
+	APIRequest KubernetesAPIRequest `yaml:"apiRequest"`
+	Report     []ReportedField      `yaml:"report"`
+}